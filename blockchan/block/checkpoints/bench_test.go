@@ -0,0 +1,52 @@
+package checkpoints
+
+import (
+	"math/big"
+	"testing"
+)
+
+func buildCheckpoints(b *testing.B, n int) CheckpointBlock {
+	b.Helper()
+
+	bl := NewBlock()
+	for i := int64(0); i < int64(n); i++ {
+		if err := bl.AddCheckpoint(big.NewInt(i), big.NewInt(i)); err != nil {
+			b.Fatalf("AddCheckpoint(%d): %v", i, err)
+		}
+	}
+	return bl
+}
+
+func benchmarkBuild(b *testing.B, n int) {
+	for i := 0; i < b.N; i++ {
+		bl := buildCheckpoints(b, n)
+		if _, err := bl.Build(); err != nil {
+			b.Fatalf("Build: %v", err)
+		}
+		bl.Release()
+	}
+}
+
+func BenchmarkBuild1e5(b *testing.B) { benchmarkBuild(b, 100000) }
+func BenchmarkBuild1e6(b *testing.B) { benchmarkBuild(b, 1000000) }
+
+func benchmarkMarshalUnmarshal(b *testing.B, n int) {
+	bl := buildCheckpoints(b, n)
+	raw, err := bl.(*Block).Marshal()
+	if err != nil {
+		b.Fatalf("Marshal: %v", err)
+	}
+	bl.Release()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst := NewBlock()
+		if err := dst.(*Block).Unmarshal(raw); err != nil {
+			b.Fatalf("Unmarshal: %v", err)
+		}
+		dst.Release()
+	}
+}
+
+func BenchmarkUnmarshal1e5(b *testing.B) { benchmarkMarshalUnmarshal(b, 100000) }
+func BenchmarkUnmarshal1e6(b *testing.B) { benchmarkMarshalUnmarshal(b, 1000000) }
@@ -1,12 +1,15 @@
 package checkpoints
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"math/big"
 	"sort"
 	"sync"
 
 	"github.com/SmartMeshFoundation/Spectrum/common"
+	"github.com/SmartMeshFoundation/Spectrum/rlp"
 	"github.com/pkg/errors"
 
 	"github.com/SmartMeshFoundation/SmartPlasma/blockchan/block"
@@ -18,24 +21,92 @@ type CheckpointBlock interface {
 	block.Block
 	AddCheckpoint(uid, number *big.Int) error
 	NumberOfCheckpoints() int64
-	GetNonce(uid *big.Int) *big.Int
+	GetNonce(uid *big.Int) (*big.Int, error)
+	IntermediateRoot() common.Hash
+	Revert(uid *big.Int) error
+	Release()
+}
+
+// ErrNotBuilt is returned when an operation requires a built block, but
+// the block has not been finalized with Build yet.
+var ErrNotBuilt = errors.New("checkpoints: block is not built")
+
+// ErrNoCheckpoint is returned by GetNonce when the block is built but
+// holds no checkpoint for the requested uid, as distinct from the block
+// simply not being built yet (ErrNotBuilt).
+var ErrNoCheckpoint = errors.New("checkpoints: no checkpoint for uid")
+
+// uIDsPoolCapacity is the backing capacity handed out by uIDsPool for a
+// fresh Block, sized for a typical checkpoint period.
+const uIDsPoolCapacity = 1024
+
+// uIDsPool recycles the backing array of Block.uIDs across successive
+// blocks, since an operator builds and discards one checkpoint block
+// per period.
+var uIDsPool = sync.Pool{
+	New: func() interface{} {
+		return make([]common.Hash, 0, uIDsPoolCapacity)
+	},
+}
+
+// treePool recycles merkle.Tree instances, and with them the Depth257
+// node storage they hold, across successive blocks: an operator builds
+// and discards one checkpoint block per period, and each block
+// otherwise allocates a fresh sparse tree for its nodes.
+var treePool = sync.Pool{}
+
+// getTree returns a reset tree from treePool, or allocates a fresh
+// Depth257 tree if the pool is empty.
+func getTree() (*merkle.Tree, error) {
+	if pooled, ok := treePool.Get().(*merkle.Tree); ok {
+		pooled.Reset()
+		return pooled, nil
+	}
+	return merkle.NewEmptyTree(merkle.Depth257)
+}
+
+// putTree returns tree to treePool so the next incremental Block can
+// reuse its node storage instead of allocating fresh.
+func putTree(tree *merkle.Tree) {
+	if tree != nil {
+		treePool.Put(tree)
+	}
 }
 
 // Block is checkpoint block object.
 type Block struct {
 	mtx     sync.Mutex
-	uIDs    []string
-	numbers map[string]common.Hash
+	uIDs    []common.Hash
+	numbers map[common.Hash]common.Hash
 	tree    *merkle.Tree
+	batch   bool
+
+	lastUID    common.Hash
+	hasLastUID bool
 
 	built bool
 }
 
-// NewBlock creates new Checkpoints block in memory.
+// NewBlock creates new Checkpoints block in memory. Checkpoints are
+// folded into a running sparse merkle tree as they are added, so Build
+// is a cheap finalization.
 func NewBlock() CheckpointBlock {
 	return &Block{
 		mtx:     sync.Mutex{},
-		numbers: make(map[string]common.Hash),
+		uIDs:    uIDsPool.Get().([]common.Hash)[:0],
+		numbers: make(map[common.Hash]common.Hash),
+	}
+}
+
+// NewBatchBlock creates new Checkpoints block in memory that defers the
+// whole merkle tree construction to Build, for callers that prefer the
+// batch path over touching the tree on every AddCheckpoint.
+func NewBatchBlock() CheckpointBlock {
+	return &Block{
+		mtx:     sync.Mutex{},
+		uIDs:    uIDsPool.Get().([]common.Hash)[:0],
+		numbers: make(map[common.Hash]common.Hash),
+		batch:   true,
 	}
 }
 
@@ -47,7 +118,10 @@ func (bl *Block) Hash() common.Hash {
 	return bl.tree.Root()
 }
 
-// AddCheckpoint adds a checkpoints to the block.
+// AddCheckpoint adds a checkpoint to the block. When possible it folds
+// the checkpoint into a running sparse merkle tree immediately,
+// touching only the O(depth) nodes on the path to uid rather than
+// deferring the whole tree construction to Build.
 func (bl *Block) AddCheckpoint(uid, number *big.Int) error {
 	if bl.built {
 		return block.ErrAlreadyBuilt
@@ -56,13 +130,32 @@ func (bl *Block) AddCheckpoint(uid, number *big.Int) error {
 	bl.mtx.Lock()
 	defer bl.mtx.Unlock()
 
-	if _, ok := bl.numbers[uid.String()]; ok {
+	uidHash := common.BigToHash(uid)
+	if _, ok := bl.numbers[uidHash]; ok {
 		return errors.Errorf("checkpoint for uid %s already"+
 			" exist in the block", uid.String())
 	}
 
-	bl.uIDs = append(bl.uIDs, uid.String())
-	bl.numbers[uid.String()] = common.BigToHash(number)
+	nonce := common.BigToHash(number)
+
+	if !bl.batch {
+		if bl.tree == nil {
+			tree, err := getTree()
+			if err != nil {
+				return errors.Wrap(err, "failed to start incremental tree")
+			}
+			bl.tree = tree
+		}
+
+		if err := bl.tree.Update(uid, nonce); err != nil {
+			return errors.Wrap(err, "failed to update incremental tree")
+		}
+	}
+
+	bl.uIDs = append(bl.uIDs, uidHash)
+	bl.numbers[uidHash] = nonce
+	bl.lastUID = uidHash
+	bl.hasLastUID = true
 	return nil
 }
 
@@ -71,7 +164,58 @@ func (bl *Block) NumberOfCheckpoints() int64 {
 	return int64(len(bl.numbers))
 }
 
-// Build finalizes the block.
+// IntermediateRoot returns the root of the running sparse merkle tree
+// built so far by AddCheckpoint, without finalizing the block. It lets
+// operators snapshot progress between adds. It returns the zero hash if
+// no checkpoint has been added yet. For a Block created with
+// NewBatchBlock it returns the zero hash until Build, since that Block
+// has no running tree before then; after Build it returns the built root.
+func (bl *Block) IntermediateRoot() common.Hash {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	if bl.tree == nil {
+		return common.Hash{}
+	}
+	return bl.tree.Root()
+}
+
+// Revert undoes the last checkpoint added with AddCheckpoint, so
+// construction can be aborted without leaving a partial entry in the
+// block. uid must match the most recently added checkpoint. Only one
+// checkpoint can be reverted per AddCheckpoint: after a Revert, further
+// Revert calls fail until another checkpoint is added.
+func (bl *Block) Revert(uid *big.Int) error {
+	if bl.built {
+		return block.ErrAlreadyBuilt
+	}
+
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	uidHash := common.BigToHash(uid)
+	if !bl.hasLastUID || uidHash != bl.lastUID {
+		return errors.Errorf("checkpoint for uid %s is not the"+
+			" last one added to the block", uid.String())
+	}
+
+	if bl.tree != nil {
+		if err := bl.tree.Update(uid, bl.tree.DefaultNodes[0]); err != nil {
+			return errors.Wrap(err, "failed to revert incremental tree")
+		}
+	}
+
+	bl.uIDs = bl.uIDs[:len(bl.uIDs)-1]
+	delete(bl.numbers, uidHash)
+	bl.hasLastUID = false
+	return nil
+}
+
+// Build finalizes the block. For a Block built incrementally (the
+// NewBlock default) the running tree's root is already correct, so
+// this is a cheap finalization; for a Block created with NewBatchBlock
+// it falls back to building the whole merkle.Tree in one shot from
+// bl.numbers, producing a byte-identical root either way.
 func (bl *Block) Build() (common.Hash, error) {
 	if bl.built {
 		return common.Hash{}, block.ErrAlreadyBuilt
@@ -80,16 +224,19 @@ func (bl *Block) Build() (common.Hash, error) {
 	bl.mtx.Lock()
 	defer bl.mtx.Unlock()
 
-	if !sort.StringsAreSorted(bl.uIDs) {
-		sort.Strings(bl.uIDs)
-	}
+	if bl.tree == nil {
+		numbers := make(map[string]common.Hash, len(bl.numbers))
+		for uidHash, nonce := range bl.numbers {
+			numbers[uidHash.Big().String()] = nonce
+		}
 
-	tree, err := merkle.NewTree(bl.numbers, merkle.Depth257)
-	if err != nil {
-		return common.Hash{}, errors.Wrap(err, "failed to build block")
+		tree, err := merkle.NewTree(numbers, merkle.Depth257)
+		if err != nil {
+			return common.Hash{}, errors.Wrap(err, "failed to build block")
+		}
+		bl.tree = tree
 	}
 
-	bl.tree = tree
 	bl.built = true
 	return bl.tree.Root(), nil
 }
@@ -99,40 +246,174 @@ func (bl *Block) IsBuilt() bool {
 	return bl.built
 }
 
-// Marshal encodes block object to raw json data.
+// Release returns the Block's backing uid slice and merkle tree to
+// their shared pools so the next Block can reuse them instead of
+// allocating fresh. The Block must not be used after calling Release.
+func (bl *Block) Release() {
+	bl.mtx.Lock()
+	uIDs := bl.uIDs
+	tree := bl.tree
+	bl.uIDs = nil
+	bl.tree = nil
+	bl.mtx.Unlock()
+
+	if uIDs != nil {
+		uIDsPool.Put(uIDs[:0])
+	}
+	putTree(tree)
+}
+
+// Marshal encodes block object to raw json data, as a map of decimal
+// uid string to nonce hash — the same wire shape Marshal has always
+// produced, regardless of the map type Block keeps internally.
 func (bl *Block) Marshal() ([]byte, error) {
-	raw, err := json.Marshal(bl.numbers)
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	numbers := make(map[string]common.Hash, len(bl.numbers))
+	for uidHash, nonce := range bl.numbers {
+		numbers[uidHash.Big().String()] = nonce
+	}
+
+	raw, err := json.Marshal(numbers)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to encode checkpoints")
 	}
-
 	return raw, nil
 }
 
-// Unmarshal decodes raw json data to block object.
+// Unmarshal decodes raw json data in the format produced by Marshal, a
+// map of decimal uid string to nonce hash. Entries are streamed one at
+// a time through a json.Decoder rather than decoded into an
+// intermediate map first, so peak memory during load is O(1) in the
+// number of checkpoints instead of holding two full copies.
 func (bl *Block) Unmarshal(raw []byte) error {
-	var checkpoints map[string]common.Hash
-
 	if len(raw) == 0 {
 		return nil
 	}
 
-	if err := json.Unmarshal(raw, &checkpoints); err != nil {
-		return errors.Wrap(err, "failed to decode"+
-			" checkpoints")
+	dec := json.NewDecoder(bytes.NewReader(raw))
+
+	if _, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "failed to decode checkpoints")
 	}
 
-	for uidStr, checkpoint := range checkpoints {
-		id, ok := new(big.Int).SetString(uidStr, 10)
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return errors.Wrap(err, "failed to decode checkpoints")
+		}
+
+		uidStr, ok := keyTok.(string)
+		if !ok {
+			return errors.Errorf(
+				"failed to decode checkpoints: unexpected key %v", keyTok)
+		}
+
+		var nonce common.Hash
+		if err := dec.Decode(&nonce); err != nil {
+			return errors.Wrap(err, "failed to decode checkpoints")
+		}
+
+		uid, ok := new(big.Int).SetString(uidStr, 10)
 		if !ok {
 			continue
 		}
 
-		if err := bl.AddCheckpoint(id, checkpoint.Big()); err != nil {
+		if err := bl.AddCheckpoint(uid, nonce.Big()); err != nil {
 			return errors.Wrap(
 				err, "failed to add checkpoint in the block")
 		}
 	}
+
+	if _, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "failed to decode checkpoints")
+	}
+	return nil
+}
+
+// rlpCheckpoint is the RLP wire representation of a single checkpoint,
+// a [uid_bytes, nonce_bytes] pair.
+type rlpCheckpoint struct {
+	UID   []byte
+	Nonce []byte
+}
+
+// EncodeRLP implements rlp.Encoder. It serializes the checkpoints as a
+// list of [uid_bytes, nonce_bytes] pairs ordered by ascending uid,
+// regardless of the order the checkpoints were added in.
+func (bl *Block) EncodeRLP(w io.Writer) error {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	uIDs := make([]common.Hash, len(bl.uIDs))
+	copy(uIDs, bl.uIDs)
+
+	if !sortedHashes(uIDs) {
+		sortHashes(uIDs)
+	}
+
+	checkpoints := make([]rlpCheckpoint, 0, len(uIDs))
+	for _, uidHash := range uIDs {
+		checkpoints = append(checkpoints, rlpCheckpoint{
+			UID:   uidHash.Big().Bytes(),
+			Nonce: bl.numbers[uidHash].Bytes(),
+		})
+	}
+
+	return rlp.Encode(w, checkpoints)
+}
+
+// DecodeRLP implements rlp.Decoder. It populates a freshly created,
+// not-yet-built Block from the RLP stream produced by EncodeRLP,
+// preserving the sorted-insertion invariant and rejecting duplicate
+// uids the same way AddCheckpoint does.
+func (bl *Block) DecodeRLP(s *rlp.Stream) error {
+	if bl.built {
+		return block.ErrAlreadyBuilt
+	}
+
+	var checkpoints []rlpCheckpoint
+	if err := s.Decode(&checkpoints); err != nil {
+		return errors.Wrap(err, "failed to decode checkpoints")
+	}
+
+	if bl.numbers == nil {
+		bl.numbers = make(map[common.Hash]common.Hash)
+	}
+
+	for _, checkpoint := range checkpoints {
+		uid := new(big.Int).SetBytes(checkpoint.UID)
+		nonce := new(big.Int).SetBytes(checkpoint.Nonce)
+
+		if err := bl.AddCheckpoint(uid, nonce); err != nil {
+			return errors.Wrap(
+				err, "failed to add checkpoint in the block")
+		}
+	}
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the compact
+// RLP wire format instead of JSON.
+func (bl *Block) MarshalBinary() ([]byte, error) {
+	raw, err := rlp.EncodeToBytes(bl)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to rlp-encode checkpoints")
+	}
+	return raw, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the
+// compact RLP wire format instead of JSON.
+func (bl *Block) UnmarshalBinary(raw []byte) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if err := rlp.DecodeBytes(raw, bl); err != nil {
+		return errors.Wrap(err, "failed to rlp-decode checkpoints")
+	}
 	return nil
 }
 
@@ -145,8 +426,13 @@ func (bl *Block) CreateProof(uid *big.Int) []byte {
 		bl.tree.DefaultNodes)
 }
 
-// GetNonce returns nonce for a particular UID.
-func (bl *Block) GetNonce(uid *big.Int) *big.Int {
+// CreateNonInclusionProof creates a merkle proof witnessing that no
+// checkpoint exists for uid in the block: the leaf at position uid in
+// the Depth257 sparse merkle tree is the default (zero) node, and the
+// returned proof is the sibling path from that empty leaf up to
+// tree.Root(). It returns nil if the block is not built or if a
+// checkpoint for uid does exist.
+func (bl *Block) CreateNonInclusionProof(uid *big.Int) []byte {
 	if !bl.built {
 		return nil
 	}
@@ -154,5 +440,65 @@ func (bl *Block) GetNonce(uid *big.Int) *big.Int {
 	bl.mtx.Lock()
 	defer bl.mtx.Unlock()
 
-	return bl.tree.GetStructure()[0][uid.String()].Big()
+	if _, ok := bl.numbers[common.BigToHash(uid)]; ok {
+		return nil
+	}
+
+	return merkle.CreateProof(uid, merkle.Depth257, bl.tree.GetStructure(),
+		bl.tree.DefaultNodes)
+}
+
+// DefaultLeaf returns the tree's default (zero) leaf node, the value
+// VerifyNonInclusionProof needs to check a proof produced by
+// CreateNonInclusionProof against this block's root. It returns the
+// zero hash if the block is not built.
+func (bl *Block) DefaultLeaf() common.Hash {
+	if !bl.built {
+		return common.Hash{}
+	}
+	return bl.tree.DefaultNodes[0]
+}
+
+// VerifyNonInclusionProof verifies that proof witnesses the absence of a
+// checkpoint for uid under root: that the leaf at position uid is the
+// tree's default (zero) node and the sibling path links it to root.
+// defaultLeaf is the tree's default leaf node, e.g. the value of
+// DefaultNodes[0] on the *merkle.Tree that produced root.
+func VerifyNonInclusionProof(
+	root common.Hash, uid *big.Int, proof []byte, defaultLeaf common.Hash) bool {
+	return merkle.VerifyProof(root, uid, merkle.Depth257, proof, defaultLeaf)
+}
+
+// GetNonce returns the nonce stored for uid. It returns ErrNotBuilt if
+// the block has not been built yet, and ErrNoCheckpoint if the block is
+// built but holds no checkpoint for uid, so callers can drive exit
+// logic off a single call.
+func (bl *Block) GetNonce(uid *big.Int) (*big.Int, error) {
+	if !bl.built {
+		return nil, ErrNotBuilt
+	}
+
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	if _, ok := bl.numbers[common.BigToHash(uid)]; !ok {
+		return nil, ErrNoCheckpoint
+	}
+
+	return bl.tree.GetStructure()[0][uid.String()].Big(), nil
+}
+
+// sortedHashes reports whether hashes is already sorted in ascending
+// byte order.
+func sortedHashes(hashes []common.Hash) bool {
+	return sort.SliceIsSorted(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+}
+
+// sortHashes sorts hashes in ascending byte order in place.
+func sortHashes(hashes []common.Hash) {
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
 }
@@ -0,0 +1,261 @@
+package checkpoints
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Spectrum/common"
+	"github.com/SmartMeshFoundation/Spectrum/rlp"
+	"github.com/pkg/errors"
+
+	"github.com/SmartMeshFoundation/SmartPlasma/blockchan/block"
+)
+
+func TestBlockRLPRoundTrip(t *testing.T) {
+	want := NewBlock()
+	for i := int64(1); i <= 5; i++ {
+		if err := want.AddCheckpoint(big.NewInt(i), big.NewInt(i*10)); err != nil {
+			t.Fatalf("AddCheckpoint(%d): %v", i, err)
+		}
+	}
+
+	wantRoot, err := want.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	raw, err := want.(*Block).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	got := NewBlock()
+	if err := got.(*Block).UnmarshalBinary(raw); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	gotRoot, err := got.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if gotRoot != wantRoot {
+		t.Fatalf("root after RLP round-trip = %s, want %s",
+			gotRoot.Hex(), wantRoot.Hex())
+	}
+}
+
+func TestBlockDecodeRLPRejectsDuplicateUID(t *testing.T) {
+	dup, err := rlp.EncodeToBytes([]rlpCheckpoint{
+		{UID: big.NewInt(1).Bytes(), Nonce: big.NewInt(10).Bytes()},
+		{UID: big.NewInt(1).Bytes(), Nonce: big.NewInt(20).Bytes()},
+	})
+	if err != nil {
+		t.Fatalf("EncodeToBytes: %v", err)
+	}
+
+	bl := NewBlock()
+	if err := bl.(*Block).UnmarshalBinary(dup); err == nil {
+		t.Fatal("UnmarshalBinary with duplicate uid = nil error, want error")
+	}
+}
+
+func TestBlockDecodeRLPRejectsAlreadyBuilt(t *testing.T) {
+	bl := NewBlock()
+	if err := bl.AddCheckpoint(big.NewInt(1), big.NewInt(10)); err != nil {
+		t.Fatalf("AddCheckpoint: %v", err)
+	}
+	if _, err := bl.Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	raw, err := rlp.EncodeToBytes([]rlpCheckpoint{
+		{UID: big.NewInt(2).Bytes(), Nonce: big.NewInt(20).Bytes()},
+	})
+	if err != nil {
+		t.Fatalf("EncodeToBytes: %v", err)
+	}
+
+	err = bl.(*Block).UnmarshalBinary(raw)
+	if errors.Cause(err) != block.ErrAlreadyBuilt {
+		t.Fatalf("UnmarshalBinary on a built block = %v, want %v",
+			err, block.ErrAlreadyBuilt)
+	}
+}
+
+func TestGetNonceErrors(t *testing.T) {
+	bl := NewBlock()
+
+	if _, err := bl.GetNonce(big.NewInt(1)); err != ErrNotBuilt {
+		t.Fatalf("GetNonce before Build = %v, want %v", err, ErrNotBuilt)
+	}
+
+	if err := bl.AddCheckpoint(big.NewInt(1), big.NewInt(10)); err != nil {
+		t.Fatalf("AddCheckpoint: %v", err)
+	}
+	if _, err := bl.Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if _, err := bl.GetNonce(big.NewInt(2)); err != ErrNoCheckpoint {
+		t.Fatalf("GetNonce for absent uid = %v, want %v", err, ErrNoCheckpoint)
+	}
+
+	nonce, err := bl.GetNonce(big.NewInt(1))
+	if err != nil {
+		t.Fatalf("GetNonce: %v", err)
+	}
+	if nonce.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("GetNonce = %s, want 10", nonce.String())
+	}
+}
+
+func TestNonInclusionProof(t *testing.T) {
+	bl := NewBlock()
+	if err := bl.AddCheckpoint(big.NewInt(1), big.NewInt(10)); err != nil {
+		t.Fatalf("AddCheckpoint: %v", err)
+	}
+	root, err := bl.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	absent := big.NewInt(2)
+	proof := bl.(*Block).CreateNonInclusionProof(absent)
+	if proof == nil {
+		t.Fatal("CreateNonInclusionProof for an absent uid returned nil")
+	}
+	if !VerifyNonInclusionProof(root, absent, proof, bl.(*Block).DefaultLeaf()) {
+		t.Fatal("VerifyNonInclusionProof rejected a genuine absence proof")
+	}
+
+	if p := bl.(*Block).CreateNonInclusionProof(big.NewInt(1)); p != nil {
+		t.Fatal("CreateNonInclusionProof for a present uid returned a proof")
+	}
+}
+
+func TestUnmarshalDecodesLegacyMapShape(t *testing.T) {
+	raw := []byte(`{"1":"0x000000000000000000000000000000000000000000000000000000000000000a","2":"0x0000000000000000000000000000000000000000000000000000000000000014"}`)
+
+	bl := NewBlock()
+	if err := bl.(*Block).Unmarshal(raw); err != nil {
+		t.Fatalf("Unmarshal of legacy map shape: %v", err)
+	}
+
+	if _, err := bl.Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	nonce, err := bl.GetNonce(big.NewInt(1))
+	if err != nil {
+		t.Fatalf("GetNonce(1): %v", err)
+	}
+	if nonce.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("GetNonce(1) = %s, want 10", nonce.String())
+	}
+
+	nonce, err = bl.GetNonce(big.NewInt(2))
+	if err != nil {
+		t.Fatalf("GetNonce(2): %v", err)
+	}
+	if nonce.Cmp(big.NewInt(20)) != 0 {
+		t.Fatalf("GetNonce(2) = %s, want 20", nonce.String())
+	}
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	want := NewBlock()
+	for i := int64(1); i <= 5; i++ {
+		if err := want.AddCheckpoint(big.NewInt(i), big.NewInt(i*10)); err != nil {
+			t.Fatalf("AddCheckpoint(%d): %v", i, err)
+		}
+	}
+
+	raw, err := want.(*Block).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got := NewBlock()
+	if err := got.(*Block).Unmarshal(raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	wantRoot, err := want.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	gotRoot, err := got.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if gotRoot != wantRoot {
+		t.Fatalf("root after Marshal/Unmarshal round-trip = %s, want %s",
+			gotRoot.Hex(), wantRoot.Hex())
+	}
+}
+
+func TestIncrementalAndBatchBuildAgree(t *testing.T) {
+	incremental := NewBlock()
+	batch := NewBatchBlock()
+
+	for i := int64(1); i <= 20; i++ {
+		if err := incremental.AddCheckpoint(big.NewInt(i), big.NewInt(i*10)); err != nil {
+			t.Fatalf("incremental.AddCheckpoint(%d): %v", i, err)
+		}
+		if err := batch.AddCheckpoint(big.NewInt(i), big.NewInt(i*10)); err != nil {
+			t.Fatalf("batch.AddCheckpoint(%d): %v", i, err)
+		}
+	}
+
+	incrementalRoot, err := incremental.Build()
+	if err != nil {
+		t.Fatalf("incremental.Build: %v", err)
+	}
+	batchRoot, err := batch.Build()
+	if err != nil {
+		t.Fatalf("batch.Build: %v", err)
+	}
+
+	if incrementalRoot != batchRoot {
+		t.Fatalf("incremental root %s != batch root %s",
+			incrementalRoot.Hex(), batchRoot.Hex())
+	}
+}
+
+func TestIntermediateRootAndRevert(t *testing.T) {
+	bl := NewBlock()
+
+	if root := bl.IntermediateRoot(); root != (common.Hash{}) {
+		t.Fatalf("IntermediateRoot before any AddCheckpoint = %s, want zero hash",
+			root.Hex())
+	}
+
+	if err := bl.AddCheckpoint(big.NewInt(1), big.NewInt(10)); err != nil {
+		t.Fatalf("AddCheckpoint: %v", err)
+	}
+	afterFirst := bl.IntermediateRoot()
+
+	if err := bl.AddCheckpoint(big.NewInt(2), big.NewInt(20)); err != nil {
+		t.Fatalf("AddCheckpoint: %v", err)
+	}
+	afterSecond := bl.IntermediateRoot()
+
+	if afterFirst == afterSecond {
+		t.Fatal("IntermediateRoot did not change after adding a second checkpoint")
+	}
+
+	if err := bl.Revert(big.NewInt(2)); err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+
+	if root := bl.IntermediateRoot(); root != afterFirst {
+		t.Fatalf("IntermediateRoot after Revert = %s, want %s",
+			root.Hex(), afterFirst.Hex())
+	}
+
+	if bl.NumberOfCheckpoints() != 1 {
+		t.Fatalf("NumberOfCheckpoints after Revert = %d, want 1",
+			bl.NumberOfCheckpoints())
+	}
+}
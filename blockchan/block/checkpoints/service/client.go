@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Spectrum/common"
+	"github.com/SmartMeshFoundation/Spectrum/rpc"
+	"github.com/pkg/errors"
+)
+
+// Client is a Go client for the checkpoints proof Service, so downstream
+// apps can fetch roots, proofs and nonces without reimplementing the
+// JSON-RPC framing themselves.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects a Client to a checkpoints Service JSON-RPC endpoint at
+// rawurl.
+func Dial(rawurl string) (*Client, error) {
+	c, err := rpc.Dial(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial checkpoints service")
+	}
+	return NewClient(c), nil
+}
+
+// NewClient wraps an already-connected rpc.Client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{rpc: c}
+}
+
+// Close closes the underlying RPC connection.
+func (c *Client) Close() {
+	c.rpc.Close()
+}
+
+// GetRoot fetches the merkle root of the checkpoint block at number.
+func (c *Client) GetRoot(ctx context.Context, number uint64) (common.Hash, error) {
+	var root common.Hash
+	if err := c.rpc.CallContext(ctx, &root, "checkpoints_getRoot", number); err != nil {
+		return common.Hash{}, errors.Wrap(err, "failed to call checkpoints_getRoot")
+	}
+	return root, nil
+}
+
+// GetBlockNumber fetches the height of the checkpoint block whose root
+// is hash.
+func (c *Client) GetBlockNumber(ctx context.Context, hash common.Hash) (uint64, error) {
+	var number uint64
+	if err := c.rpc.CallContext(
+		ctx, &number, "checkpoints_getBlockNumber", hash); err != nil {
+		return 0, errors.Wrap(err, "failed to call checkpoints_getBlockNumber")
+	}
+	return number, nil
+}
+
+// GetProof fetches the merkle proof for uid against the checkpoint
+// block at number.
+func (c *Client) GetProof(ctx context.Context, number uint64, uid *big.Int) ([]byte, error) {
+	var proof []byte
+	if err := c.rpc.CallContext(
+		ctx, &proof, "checkpoints_getProof", number, uid); err != nil {
+		return nil, errors.Wrap(err, "failed to call checkpoints_getProof")
+	}
+	return proof, nil
+}
+
+// GetNonce fetches the nonce stored for uid in the checkpoint block at
+// number.
+func (c *Client) GetNonce(ctx context.Context, number uint64, uid *big.Int) (*big.Int, error) {
+	var nonce *big.Int
+	if err := c.rpc.CallContext(
+		ctx, &nonce, "checkpoints_getNonce", number, uid); err != nil {
+		return nil, errors.Wrap(err, "failed to call checkpoints_getNonce")
+	}
+	return nonce, nil
+}
+
+// SubscribeNewRoots subscribes to newly registered checkpoint roots,
+// delivering them on roots until the context is canceled or the
+// subscription is unsubscribed.
+func (c *Client) SubscribeNewRoots(
+	ctx context.Context, roots chan<- Root) (*rpc.ClientSubscription, error) {
+	sub, err := c.rpc.Subscribe(ctx, "checkpoints", roots, "newRoots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to subscribe to checkpoints_newRoots")
+	}
+	return sub, nil
+}
@@ -0,0 +1,181 @@
+// Package service exposes built checkpoint blocks to light clients over
+// JSON-RPC, so wallets can fetch merkle proofs without ever handling a
+// full checkpoints.Block object themselves.
+package service
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/SmartMeshFoundation/Spectrum/common"
+	"github.com/SmartMeshFoundation/Spectrum/event"
+	"github.com/SmartMeshFoundation/Spectrum/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/SmartMeshFoundation/SmartPlasma/blockchan/block/checkpoints"
+	"github.com/SmartMeshFoundation/SmartPlasma/merkle"
+)
+
+// ErrUnknownBlock is returned when a requested block number or hash has
+// not been registered with the Service.
+var ErrUnknownBlock = errors.New("checkpoints service: unknown block")
+
+// ErrBlockNotBuilt is returned when RegisterBlock is called with a
+// block that has not finished Build yet.
+var ErrBlockNotBuilt = errors.New("checkpoints service: block is not built")
+
+// Root is the payload streamed to subscribers whenever a new checkpoint
+// block is registered with the Service.
+type Root struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// Service serves merkle roots, proofs and nonces for a set of built
+// checkpoints.CheckpointBlock values over JSON-RPC, and notifies
+// subscribers as new blocks are registered. It holds blocks by their
+// height, and indexes their Hash() back to that height so a caller
+// that only knows a root can look up the height GetProof/GetNonce need.
+type Service struct {
+	mtx      sync.RWMutex
+	byHeight map[uint64]checkpoints.CheckpointBlock
+	byHash   map[common.Hash]uint64
+
+	feed event.Feed
+}
+
+// NewService creates an empty Service. Built blocks are added with
+// RegisterBlock as the operator produces them.
+func NewService() *Service {
+	return &Service{
+		byHeight: make(map[uint64]checkpoints.CheckpointBlock),
+		byHash:   make(map[common.Hash]uint64),
+	}
+}
+
+// RegisterBlock makes a built checkpoint block available at the given
+// height and notifies subscribers of its root. It returns
+// ErrBlockNotBuilt if bl has not been finalized with Build.
+func (s *Service) RegisterBlock(number uint64, bl checkpoints.CheckpointBlock) error {
+	if !bl.IsBuilt() {
+		return ErrBlockNotBuilt
+	}
+
+	root := bl.Hash()
+
+	s.mtx.Lock()
+	s.byHeight[number] = bl
+	s.byHash[root] = number
+	s.mtx.Unlock()
+
+	s.feed.Send(Root{Number: number, Hash: root})
+	return nil
+}
+
+// GetRoot returns the merkle root of the block at the given height.
+func (s *Service) GetRoot(_ context.Context, number uint64) (common.Hash, error) {
+	bl, err := s.blockAt(number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return bl.Hash(), nil
+}
+
+// GetBlockNumber returns the height of the checkpoint block whose root
+// is hash, so a caller that only has a root (e.g. from an on-chain
+// commitment) can look up the height GetProof/GetNonce need.
+func (s *Service) GetBlockNumber(_ context.Context, hash common.Hash) (uint64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	number, ok := s.byHash[hash]
+	if !ok {
+		return 0, ErrUnknownBlock
+	}
+	return number, nil
+}
+
+// GetProof returns the merkle proof for uid against the block at the
+// given height. It verifies the proof it built against merkle.Depth257
+// before returning it, so a corrupted internal tree is never served.
+func (s *Service) GetProof(_ context.Context, number uint64, uid *big.Int) ([]byte, error) {
+	bl, err := s.blockAt(number)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := bl.GetNonce(uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up nonce for proof")
+	}
+
+	proof := bl.CreateProof(uid)
+	if proof == nil {
+		return nil, errors.Errorf(
+			"checkpoints service: no proof for uid %s at block %d",
+			uid.String(), number)
+	}
+
+	if !merkle.VerifyProof(bl.Hash(), uid, merkle.Depth257, proof, common.BigToHash(nonce)) {
+		return nil, errors.Errorf(
+			"checkpoints service: built an invalid proof for uid %s"+
+				" at block %d", uid.String(), number)
+	}
+
+	return proof, nil
+}
+
+// GetNonce returns the nonce stored for uid in the block at the given
+// height.
+func (s *Service) GetNonce(_ context.Context, number uint64, uid *big.Int) (*big.Int, error) {
+	bl, err := s.blockAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return bl.GetNonce(uid)
+}
+
+// NewRoots notifies the subscriber of every checkpoint root registered
+// with the Service from this point on.
+func (s *Service) NewRoots(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	roots := make(chan Root)
+	sub := s.feed.Subscribe(roots)
+
+	go func() {
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case root := <-roots:
+				_ = notifier.Notify(rpcSub.ID, root)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+func (s *Service) blockAt(number uint64) (checkpoints.CheckpointBlock, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	bl, ok := s.byHeight[number]
+	if !ok {
+		return nil, ErrUnknownBlock
+	}
+	return bl, nil
+}
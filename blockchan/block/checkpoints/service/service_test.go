@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/Spectrum/rpc"
+
+	"github.com/SmartMeshFoundation/SmartPlasma/blockchan/block/checkpoints"
+)
+
+func buildTestBlock(t *testing.T) checkpoints.CheckpointBlock {
+	t.Helper()
+
+	bl := checkpoints.NewBlock()
+	for i := int64(1); i <= 3; i++ {
+		if err := bl.AddCheckpoint(big.NewInt(i), big.NewInt(i*10)); err != nil {
+			t.Fatalf("AddCheckpoint(%d): %v", i, err)
+		}
+	}
+	if _, err := bl.Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return bl
+}
+
+func TestServiceRegisterBlockRejectsUnbuilt(t *testing.T) {
+	s := NewService()
+	if err := s.RegisterBlock(1, checkpoints.NewBlock()); err != ErrBlockNotBuilt {
+		t.Fatalf("RegisterBlock with unbuilt block = %v, want %v",
+			err, ErrBlockNotBuilt)
+	}
+}
+
+func TestServiceGetRootAndBlockNumber(t *testing.T) {
+	s := NewService()
+	bl := buildTestBlock(t)
+
+	if err := s.RegisterBlock(7, bl); err != nil {
+		t.Fatalf("RegisterBlock: %v", err)
+	}
+
+	root, err := s.GetRoot(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetRoot: %v", err)
+	}
+	if root != bl.Hash() {
+		t.Fatalf("GetRoot = %s, want %s", root.Hex(), bl.Hash().Hex())
+	}
+
+	number, err := s.GetBlockNumber(context.Background(), root)
+	if err != nil {
+		t.Fatalf("GetBlockNumber: %v", err)
+	}
+	if number != 7 {
+		t.Fatalf("GetBlockNumber = %d, want 7", number)
+	}
+}
+
+func TestServiceGetProofAndNonce(t *testing.T) {
+	s := NewService()
+	bl := buildTestBlock(t)
+
+	if err := s.RegisterBlock(1, bl); err != nil {
+		t.Fatalf("RegisterBlock: %v", err)
+	}
+
+	uid := big.NewInt(2)
+
+	nonce, err := s.GetNonce(context.Background(), 1, uid)
+	if err != nil {
+		t.Fatalf("GetNonce: %v", err)
+	}
+	if nonce.Cmp(big.NewInt(20)) != 0 {
+		t.Fatalf("GetNonce = %s, want 20", nonce.String())
+	}
+
+	proof, err := s.GetProof(context.Background(), 1, uid)
+	if err != nil {
+		t.Fatalf("GetProof: %v", err)
+	}
+	if len(proof) == 0 {
+		t.Fatal("GetProof returned an empty proof")
+	}
+}
+
+func TestServiceSubscribeNewRoots(t *testing.T) {
+	s := NewService()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("checkpoints", s); err != nil {
+		t.Fatalf("RegisterName: %v", err)
+	}
+
+	rpcClient := rpc.DialInProc(server)
+	client := NewClient(rpcClient)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	roots := make(chan Root)
+	sub, err := client.SubscribeNewRoots(ctx, roots)
+	if err != nil {
+		t.Fatalf("SubscribeNewRoots: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	bl := buildTestBlock(t)
+	if err := s.RegisterBlock(9, bl); err != nil {
+		t.Fatalf("RegisterBlock: %v", err)
+	}
+
+	select {
+	case root := <-roots:
+		if root.Number != 9 {
+			t.Fatalf("notified root.Number = %d, want 9", root.Number)
+		}
+		if root.Hash != bl.Hash() {
+			t.Fatalf("notified root.Hash = %s, want %s",
+				root.Hash.Hex(), bl.Hash().Hex())
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription error: %v", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for new root notification")
+	}
+}
+
+func TestServiceUnknownBlock(t *testing.T) {
+	s := NewService()
+
+	if _, err := s.GetRoot(context.Background(), 42); err != ErrUnknownBlock {
+		t.Fatalf("GetRoot for unknown block = %v, want %v", err, ErrUnknownBlock)
+	}
+	if _, err := s.GetBlockNumber(context.Background(), [32]byte{}); err != ErrUnknownBlock {
+		t.Fatalf("GetBlockNumber for unknown root = %v, want %v", err, ErrUnknownBlock)
+	}
+}